@@ -0,0 +1,583 @@
+// Command lc3asm assembles LC-3 assembly source into the big-endian .obj
+// ROM format consumed by RetrieveROM in the lc3-vm interpreter.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sandeepkalra/go-lc3-vm/isa"
+)
+
+func main() {
+	outPath := flag.String("o", "", "output .obj path (default: <input>.obj)")
+	writeSym := flag.Bool("sym", false, "also emit a .sym file mapping addresses to labels")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: lc3asm [-o out.obj] [-sym] <input.asm>")
+		os.Exit(2)
+	}
+	inPath := flag.Arg(0)
+
+	src, err := os.ReadFile(inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lc3asm: %v\n", err)
+		os.Exit(1)
+	}
+
+	asm := newAssembler(strings.Split(string(src), "\n"))
+	if err := asm.assemble(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	obj := *outPath
+	if obj == "" {
+		obj = strings.TrimSuffix(inPath, filepath.Ext(inPath)) + ".obj"
+	}
+	if err := asm.writeObj(obj); err != nil {
+		fmt.Fprintf(os.Stderr, "lc3asm: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *writeSym {
+		sym := strings.TrimSuffix(obj, filepath.Ext(obj)) + ".sym"
+		if err := asm.writeSym(sym); err != nil {
+			fmt.Fprintf(os.Stderr, "lc3asm: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// diagnostic is an assembler error tied to a source line/column, matching
+// the line:col style of errors a text editor can jump to.
+type diagnostic struct {
+	line, col int
+	msg       string
+}
+
+func (d diagnostic) Error() string {
+	return fmt.Sprintf("%d:%d: %s", d.line, d.col, d.msg)
+}
+
+// diagnostics collects multiple diagnostics from a single assembler pass.
+type diagnostics []diagnostic
+
+func (ds diagnostics) Error() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stmt is one parsed line of source: an optional label, a mnemonic or
+// directive, and its operand tokens. addr is filled in during pass 1.
+type stmt struct {
+	line     int
+	label    string
+	mnemonic string
+	operands []string
+	addr     uint16
+}
+
+// assembler runs the two-pass LC-3 assembly: pass 1 builds the symbol
+// table, pass 2 encodes every stmt into words.
+type assembler struct {
+	rawLines []string
+	stmts    []stmt
+	symbols  map[string]uint16
+	origin   uint16
+	words    []uint16 // words[i] is the encoding for origin+i
+	diags    diagnostics
+}
+
+func newAssembler(rawLines []string) *assembler {
+	return &assembler{rawLines: rawLines, symbols: map[string]uint16{}}
+}
+
+// directives and trap aliases round out the mnemonic set isa.Mnemonics
+// gives us, so the parser can tell a label from an opcode.
+var directives = map[string]bool{
+	".ORIG": true, ".END": true, ".FILL": true, ".BLKW": true, ".STRINGZ": true,
+}
+
+// pseudoMnemonics are encode()'s own shorthands that don't appear in
+// isa.Mnemonics: the BR condition-code variants, RET (JMP R7) and JSRR
+// (JSR's register-indirect form).
+var pseudoMnemonics = map[string]bool{
+	"BRN": true, "BRZ": true, "BRP": true, "BRNZ": true, "BRNP": true, "BRZP": true, "BRNZP": true,
+	"RET": true, "JSRR": true,
+}
+
+func isKnownMnemonic(tok string) bool {
+	tok = strings.ToUpper(tok)
+	if directives[tok] || tok == "TRAP" || pseudoMnemonics[tok] {
+		return true
+	}
+	if _, ok := isa.TrapAliases[tok]; ok {
+		return true
+	}
+	for _, m := range isa.Mnemonics {
+		if m == tok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *assembler) assemble() error {
+	a.pass1()
+	if len(a.diags) > 0 {
+		return a.diags
+	}
+	a.pass2()
+	if len(a.diags) > 0 {
+		return a.diags
+	}
+	return nil
+}
+
+// pass1 tokenizes every source line, resolves .ORIG/.END, records label
+// addresses, and reserves space for every directive and instruction.
+func (a *assembler) pass1() {
+	addr := uint16(0)
+	sawOrig := false
+
+	for i, raw := range a.rawLines {
+		lineNo := i + 1
+		toks := tokenize(raw)
+		if len(toks) == 0 {
+			continue
+		}
+
+		if strings.EqualFold(toks[0], ".ORIG") {
+			if len(toks) < 2 {
+				a.errf(lineNo, 1, ".ORIG requires an address operand")
+				continue
+			}
+			v, err := a.parseNum(toks[1])
+			if err != nil {
+				a.errf(lineNo, 1, "bad .ORIG operand: %v", err)
+				continue
+			}
+			a.origin = v
+			addr = v
+			sawOrig = true
+			continue
+		}
+
+		if !sawOrig {
+			a.errf(lineNo, 1, "statement before .ORIG")
+			continue
+		}
+
+		label := ""
+		if !isKnownMnemonic(toks[0]) {
+			label = toks[0]
+			toks = toks[1:]
+		}
+		if label != "" {
+			if _, dup := a.symbols[label]; dup {
+				a.errf(lineNo, 1, "duplicate label %q", label)
+			}
+			a.symbols[label] = addr
+		}
+		if len(toks) == 0 {
+			continue // label-only line
+		}
+
+		mnemonic := strings.ToUpper(toks[0])
+		if mnemonic == ".END" {
+			break
+		}
+		operands := toks[1:]
+
+		size, err := stmtSize(mnemonic, operands)
+		if err != nil {
+			a.errf(lineNo, 1, "%v", err)
+			continue
+		}
+
+		a.stmts = append(a.stmts, stmt{line: lineNo, label: label, mnemonic: mnemonic, operands: operands, addr: addr})
+		addr += size
+	}
+}
+
+// stmtSize returns how many 16-bit words a statement occupies.
+func stmtSize(mnemonic string, operands []string) (uint16, error) {
+	switch mnemonic {
+	case ".BLKW":
+		if len(operands) != 1 {
+			return 0, fmt.Errorf(".BLKW requires one operand")
+		}
+		n, err := strconv.ParseUint(strings.TrimPrefix(operands[0], "#"), 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("bad .BLKW count: %v", err)
+		}
+		return uint16(n), nil
+	case ".STRINGZ":
+		if len(operands) != 1 {
+			return 0, fmt.Errorf(".STRINGZ requires one string operand")
+		}
+		s, err := unquote(operands[0])
+		if err != nil {
+			return 0, err
+		}
+		return uint16(len(s) + 1), nil
+	case ".FILL":
+		return 1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// pass2 encodes every stmt recorded in pass 1 into a.words.
+func (a *assembler) pass2() {
+	a.words = make([]uint16, 0, len(a.stmts))
+	for _, s := range a.stmts {
+		words, err := a.encode(s)
+		if err != nil {
+			a.errf(s.line, 1, "%v", err)
+			continue
+		}
+		a.words = append(a.words, words...)
+	}
+}
+
+func (a *assembler) encode(s stmt) ([]uint16, error) {
+	ops := s.operands
+	switch s.mnemonic {
+	case ".FILL":
+		if len(ops) != 1 {
+			return nil, fmt.Errorf(".FILL requires one operand")
+		}
+		v, err := a.parseNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		return []uint16{v}, nil
+	case ".BLKW":
+		n, _ := stmtSize(".BLKW", ops)
+		return make([]uint16, n), nil
+	case ".STRINGZ":
+		str, err := unquote(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		words := make([]uint16, len(str)+1)
+		for i, r := range []byte(str) {
+			words[i] = uint16(r)
+		}
+		return words, nil
+	case "BR", "BRN", "BRZ", "BRP", "BRNZ", "BRNP", "BRZP", "BRNZP":
+		n := strings.Contains(s.mnemonic, "N")
+		z := strings.Contains(s.mnemonic, "Z")
+		p := strings.Contains(s.mnemonic, "P")
+		if s.mnemonic == "BR" {
+			n, z, p = true, true, true
+		}
+		off, err := a.pcOffset(s, ops[0], isa.PCOffset9Bits)
+		if err != nil {
+			return nil, err
+		}
+		word := uint16(isa.OpBR) << 12
+		if n {
+			word |= 1 << 11
+		}
+		if z {
+			word |= 1 << 10
+		}
+		if p {
+			word |= 1 << 9
+		}
+		word |= off & mask(isa.PCOffset9Bits)
+		return []uint16{word}, nil
+	case "ADD", "AND":
+		if len(ops) != 3 {
+			return nil, fmt.Errorf("%s requires 3 operands", s.mnemonic)
+		}
+		dr, err := regNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		sr1, err := regNum(ops[1])
+		if err != nil {
+			return nil, err
+		}
+		op := isa.OpADD
+		if s.mnemonic == "AND" {
+			op = isa.OpAND
+		}
+		word := uint16(op)<<12 | dr<<9 | sr1<<6
+		if sr2, err := regNum(ops[2]); err == nil {
+			word |= sr2
+		} else {
+			imm, err := a.parseNum(ops[2])
+			if err != nil {
+				return nil, err
+			}
+			if !fitsSigned(imm, isa.Imm5Bits) {
+				return nil, fmt.Errorf("immediate %d does not fit in %d bits", int16(imm), isa.Imm5Bits)
+			}
+			word |= 1 << 5
+			word |= imm & mask(isa.Imm5Bits)
+		}
+		return []uint16{word}, nil
+	case "NOT":
+		if len(ops) != 2 {
+			return nil, fmt.Errorf("NOT requires 2 operands")
+		}
+		dr, err := regNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		sr, err := regNum(ops[1])
+		if err != nil {
+			return nil, err
+		}
+		return []uint16{uint16(isa.OpNOT)<<12 | dr<<9 | sr<<6 | 0x3F}, nil
+	case "LD", "LDI", "ST", "STI", "LEA":
+		if len(ops) != 2 {
+			return nil, fmt.Errorf("%s requires 2 operands", s.mnemonic)
+		}
+		dr, err := regNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		off, err := a.pcOffset(s, ops[1], isa.PCOffset9Bits)
+		if err != nil {
+			return nil, err
+		}
+		op := map[string]isa.Op{"LD": isa.OpLD, "LDI": isa.OpLDI, "ST": isa.OpST, "STI": isa.OpSTI, "LEA": isa.OpLEA}[s.mnemonic]
+		return []uint16{uint16(op)<<12 | dr<<9 | (off & mask(isa.PCOffset9Bits))}, nil
+	case "LDR", "STR":
+		if len(ops) != 3 {
+			return nil, fmt.Errorf("%s requires 3 operands", s.mnemonic)
+		}
+		dr, err := regNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		base, err := regNum(ops[1])
+		if err != nil {
+			return nil, err
+		}
+		off, err := a.parseNum(ops[2])
+		if err != nil {
+			return nil, err
+		}
+		if !fitsSigned(off, isa.Offset6Bits) {
+			return nil, fmt.Errorf("offset %d does not fit in %d bits", int16(off), isa.Offset6Bits)
+		}
+		op := isa.OpLDR
+		if s.mnemonic == "STR" {
+			op = isa.OpSTR
+		}
+		return []uint16{uint16(op)<<12 | dr<<9 | base<<6 | (off & mask(isa.Offset6Bits))}, nil
+	case "JMP":
+		if len(ops) != 1 {
+			return nil, fmt.Errorf("JMP requires 1 operand")
+		}
+		base, err := regNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		return []uint16{uint16(isa.OpJMP)<<12 | base<<6}, nil
+	case "RET":
+		return []uint16{uint16(isa.OpJMP)<<12 | 7<<6}, nil
+	case "JSR":
+		if len(ops) != 1 {
+			return nil, fmt.Errorf("JSR requires 1 operand")
+		}
+		off, err := a.pcOffset(s, ops[0], isa.PCOffset11Bits)
+		if err != nil {
+			return nil, err
+		}
+		return []uint16{uint16(isa.OpJSR)<<12 | 1<<11 | (off & mask(isa.PCOffset11Bits))}, nil
+	case "JSRR":
+		if len(ops) != 1 {
+			return nil, fmt.Errorf("JSRR requires 1 operand")
+		}
+		base, err := regNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		return []uint16{uint16(isa.OpJSR)<<12 | base<<6}, nil
+	case "RTI":
+		return []uint16{uint16(isa.OpRTI) << 12}, nil
+	case "TRAP":
+		if len(ops) != 1 {
+			return nil, fmt.Errorf("TRAP requires 1 operand")
+		}
+		v, err := a.parseNum(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		return []uint16{uint16(isa.OpTRAP)<<12 | (v & 0xFF)}, nil
+	default:
+		if trap, ok := isa.TrapAliases[s.mnemonic]; ok {
+			return []uint16{uint16(isa.OpTRAP)<<12 | uint16(trap)}, nil
+		}
+		return nil, fmt.Errorf("unknown mnemonic %q", s.mnemonic)
+	}
+}
+
+// pcOffset resolves operand to a PC-relative offset from s (a label or a
+// literal #/x value) and checks it fits in bits.
+func (a *assembler) pcOffset(s stmt, operand string, bits int) (uint16, error) {
+	var target uint16
+	if addr, ok := a.symbols[operand]; ok {
+		target = addr
+	} else {
+		v, err := a.parseNum(operand)
+		if err != nil {
+			return 0, fmt.Errorf("unresolved label or literal %q", operand)
+		}
+		target = v
+	}
+	off := int32(target) - int32(s.addr) - 1
+	if !fitsSigned(uint16(off), bits) {
+		return 0, fmt.Errorf("offset to %q (%d) does not fit in %d bits", operand, off, bits)
+	}
+	return uint16(off), nil
+}
+
+func regNum(tok string) (uint16, error) {
+	tok = strings.ToUpper(tok)
+	if len(tok) == 2 && tok[0] == 'R' && tok[1] >= '0' && tok[1] <= '7' {
+		return uint16(tok[1] - '0'), nil
+	}
+	return 0, fmt.Errorf("expected register, got %q", tok)
+}
+
+func (a *assembler) parseNum(tok string) (uint16, error) {
+	switch {
+	case strings.HasPrefix(tok, "#"):
+		v, err := strconv.ParseInt(tok[1:], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("bad decimal literal %q: %v", tok, err)
+		}
+		return uint16(v), nil
+	case strings.HasPrefix(tok, "x") || strings.HasPrefix(tok, "X"):
+		v, err := strconv.ParseInt(tok[1:], 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("bad hex literal %q: %v", tok, err)
+		}
+		return uint16(v), nil
+	case tok != "" && (tok[0] == '-' || (tok[0] >= '0' && tok[0] <= '9')):
+		v, err := strconv.ParseInt(tok, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("bad literal %q: %v", tok, err)
+		}
+		return uint16(v), nil
+	default:
+		if addr, ok := a.symbols[tok]; ok {
+			return addr, nil
+		}
+		return 0, fmt.Errorf("undefined symbol %q", tok)
+	}
+}
+
+func mask(bits int) uint16 {
+	return uint16(1<<uint(bits)) - 1
+}
+
+func fitsSigned(v uint16, bits int) bool {
+	sv := int32(int16(v))
+	lo := -(int32(1) << (bits - 1))
+	hi := int32(1)<<(bits-1) - 1
+	return sv >= lo && sv <= hi
+}
+
+func unquote(tok string) (string, error) {
+	if len(tok) < 2 || tok[0] != '"' || tok[len(tok)-1] != '"' {
+		return "", fmt.Errorf("expected quoted string, got %q", tok)
+	}
+	return tok[1 : len(tok)-1], nil
+}
+
+// tokenize splits one source line into whitespace/comma-separated tokens,
+// stripping a ';' end-of-line comment. Quoted strings (for .STRINGZ) are
+// kept intact as a single token.
+func tokenize(line string) []string {
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	var toks []string
+	var cur strings.Builder
+	inStr := false
+	flush := func() {
+		if cur.Len() > 0 {
+			toks = append(toks, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inStr = !inStr
+			cur.WriteRune(r)
+		case inStr:
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t' || r == ',':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return toks
+}
+
+func (a *assembler) errf(line, col int, format string, args ...interface{}) {
+	a.diags = append(a.diags, diagnostic{line: line, col: col, msg: fmt.Sprintf(format, args...)})
+}
+
+// writeObj writes the assembled program in the big-endian .obj format
+// RetrieveROM expects: a 16-bit origin header followed by the words.
+func (a *assembler) writeObj(path string) error {
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.BigEndian, a.origin); err != nil {
+		return err
+	}
+	for _, w := range a.words {
+		if err := binary.Write(buf, binary.BigEndian, w); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// writeSym writes a ".sym" file mapping every label to its resolved
+// address, for the lc3dbg debugger to load.
+func (a *assembler) writeSym(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "// Symbol table")
+	fmt.Fprintln(w, "// Scope level 0:")
+	fmt.Fprintln(w, "//\tSymbol Name                       Page Address")
+	fmt.Fprintln(w, "//\t----------------------------------  -------------")
+	for label, addr := range a.symbols {
+		fmt.Fprintf(w, "//\t%-34s  %04X\n", label, addr)
+	}
+	return w.Flush()
+}
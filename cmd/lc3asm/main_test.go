@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+// TestAssembleAddHalt assembles a minimal two-instruction program and checks
+// the encoded words, guarding the pass1/pass2/encode pipeline end to end.
+func TestAssembleAddHalt(t *testing.T) {
+	src := []string{
+		".ORIG x3000",
+		"AND R0, R0, #0",
+		"ADD R0, R0, #5",
+		"TRAP x25",
+		".END",
+	}
+
+	a := newAssembler(src)
+	if err := a.assemble(); err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+
+	want := []uint16{0x5020, 0x1025, 0xF025}
+	if len(a.words) != len(want) {
+		t.Fatalf("got %d words, want %d: %04X", len(a.words), len(want), a.words)
+	}
+	for i, w := range want {
+		if a.words[i] != w {
+			t.Errorf("words[%d] = 0x%04X, want 0x%04X", i, a.words[i], w)
+		}
+	}
+}
+
+// TestAssembleRetAndJSRR guards against isKnownMnemonic not recognizing
+// encode()'s own RET/JSRR pseudo-mnemonics: pass1 would otherwise swallow
+// a bare "RET" as a label (dropping it from the output entirely) and treat
+// "JSRR"'s operand as an unknown mnemonic.
+func TestAssembleRetAndJSRR(t *testing.T) {
+	src := []string{
+		".ORIG x3000",
+		"ADD R0, R0, #1",
+		"RET",
+		"JSRR R1",
+		".END",
+	}
+
+	a := newAssembler(src)
+	if err := a.assemble(); err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+
+	want := []uint16{0x1021, 0xC1C0, 0x4040}
+	if len(a.words) != len(want) {
+		t.Fatalf("got %d words, want %d: %04X", len(a.words), len(want), a.words)
+	}
+	for i, w := range want {
+		if a.words[i] != w {
+			t.Errorf("words[%d] = 0x%04X, want 0x%04X", i, a.words[i], w)
+		}
+	}
+}
+
+// TestAssembleUnknownMnemonicFails checks that a bogus opcode produces a
+// diagnostic rather than silently encoding garbage.
+func TestAssembleUnknownMnemonicFails(t *testing.T) {
+	src := []string{
+		".ORIG x3000",
+		"FROB R0, R0, #0",
+		".END",
+	}
+
+	a := newAssembler(src)
+	if err := a.assemble(); err == nil {
+		t.Fatalf("assemble: expected an error for an unknown mnemonic, got nil")
+	}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"time"
+
+	"github.com/sandeepkalra/go-lc3-vm/isa"
 )
 
+// errNoProgram is returned by Run when the CPU has no program loaded into
+// memory to execute.
+var errNoProgram = errors.New("no program loaded into memory")
+
 // RunState specifies the current running state of the Processor.
 type RunState uint32
 
@@ -28,11 +34,22 @@ type CPU struct {
 	//Memory       []byte        // CPU Memory
 	CondRegister *CondRegister // Condition Flags Register
 
-	TimerStarted bool
-	TimerStart   time.Time
+	Bus *Bus // memory-mapped I/O devices (keyboard, display, MCR, timer)
 
 	OP       uint16   // current opcode
 	runState RunState // current state
+
+	oracle Oracle // observes memory accesses during StepWithProof, if set
+
+	interpMode  InterpMode          // dispatch strategy Step uses; defaults to InterpSwitch
+	decodeCache [65536]*DecodedInst // per-address decode, used by InterpThreaded/InterpBlocks
+	blockCache  [65536]*block       // per-address basic block, used by InterpBlocks
+}
+
+// SetInterpMode selects how Step dispatches instructions. The zero value
+// (InterpSwitch) is the original switch-based EmulateInstruction path.
+func (c *CPU) SetInterpMode(mode InterpMode) {
+	c.interpMode = mode
 }
 
 // CondRegister stores the state of the CPU condition flags register.
@@ -51,40 +68,20 @@ const (
 	MemRegKBDR uint16 = 0xFE02
 )
 
-// List of OpCodes
-const (
-	OpBR   uint16 = iota // branch
-	OpADD                // add
-	OpLD                 // load
-	OpST                 // store
-	OpJSR                // jump register
-	OpAND                // bitwise and
-	OpLDR                // load register
-	OpSTR                // store register
-	OpRTI                // unused
-	OpNOT                // bitwise not
-	OpLDI                // load indirect
-	OpSTI                // store indrect
-	OpJMP                // jump
-	OpRES                // reserved (unused)
-	OpLEA                // load effective address
-	OpTRAP               // execute trap
-)
+// NewCPU creates a new instance of the CPU, wired to the default device
+// Bus: a Keyboard on stdin, a Display on stdout, a MachineControl register
+// that halts the CPU, and a disarmed Timer.
+func NewCPU() *CPU {
+	cpu := &CPU{}
 
-// List of Trap codes
-const (
-	TrapGETC  uint16 = 0x20 // get character from keyboard
-	TrapOUT   uint16 = 0x21 // output a character
-	TrapPUTS  uint16 = 0x22 // output a word string
-	TrapIN    uint16 = 0x23 // input a string
-	TrapPUTSP uint16 = 0x24 // output a byte string
-	TrapHALT  uint16 = 0x25 // halt the program
-)
+	bus := NewBus()
+	bus.Register(NewKeyboard(os.Stdin))
+	bus.Register(NewDisplay(os.Stdout))
+	bus.Register(NewMachineControl(func() { cpu.Stop() }))
+	bus.Register(NewTimer())
+	cpu.Bus = bus
 
-// NewCPU creates a new instance of the CPU
-func NewCPU() *CPU {
-	cpu := CPU{}
-	return &cpu
+	return cpu
 }
 
 // Run executes any program loaded into memory, starting from the program
@@ -121,10 +118,27 @@ func (c *CPU) Reset() {
 // Step executes the program loaded into memory
 func (c *CPU) Step() (err error) {
 	c.runState = RunStateRunning
-	//fmt.Println("PC: ", c.PC)
-	c.EmulateInstruction()
-	//Increment MCC
-	c.Memory[0xFFFF]++
+
+	switch c.interpMode {
+	case InterpThreaded:
+		c.stepThreaded()
+		c.WriteMemory(0xFFFF, c.Memory[0xFFFF]+1) // Increment MCC
+		if c.Bus != nil {
+			c.Bus.Tick(1)
+		}
+	case InterpBlocks:
+		// stepBlock runs a whole cached block, ticking the MCC/Bus once
+		// per instruction inside it, so it handles that bookkeeping itself.
+		c.stepBlock()
+	default:
+		//fmt.Println("PC: ", c.PC)
+		c.EmulateInstruction()
+		c.WriteMemory(0xFFFF, c.Memory[0xFFFF]+1) // Increment MCC
+		if c.Bus != nil {
+			c.Bus.Tick(1)
+		}
+	}
+
 	return
 }
 
@@ -138,10 +152,23 @@ func (c *CPU) Stop() (err error) {
 func (c *CPU) ReadMemory(address uint16) uint16 {
 	//log.Printf("Reading address: 0x%04X", address)
 
+	if c.Bus != nil {
+		if val, ok := c.Bus.Read(address); ok {
+			if c.oracle != nil {
+				c.oracle.OnRead(address, val)
+			}
+			return val
+		}
+	}
+
 	switch {
 	case address <= 65535:
 		//log.Printf("Value is: %d", c.Memory[address])
-		return uint16(c.Memory[address])
+		val := uint16(c.Memory[address])
+		if c.oracle != nil {
+			c.oracle.OnRead(address, val)
+		}
+		return val
 	default:
 		log.Fatalf("unhandled cpu memory read at address: 0x%04X", address)
 	}
@@ -150,9 +177,24 @@ func (c *CPU) ReadMemory(address uint16) uint16 {
 
 // Write memory
 func (c *CPU) WriteMemory(address uint16, value uint16) {
+	if c.Bus != nil && c.Bus.Write(address, value) {
+		if c.oracle != nil {
+			c.oracle.OnWrite(address, value)
+		}
+		return
+	}
+
 	switch {
 	case address <= 65535:
 		c.Memory[address] = value
+		// Self-modifying code: drop any decode cached for this address.
+		// A block starting elsewhere that merely contains this address is
+		// not invalidated; that's a known limitation of this simple scheme.
+		c.decodeCache[address] = nil
+		c.blockCache[address] = nil
+		if c.oracle != nil {
+			c.oracle.OnWrite(address, value)
+		}
 	default:
 		log.Fatalf("unhandled cpu memory write at address: 0x%04X", address)
 	}
@@ -163,12 +205,12 @@ func (c *CPU) EmulateInstruction() (err error) {
 	var pc uint16 = c.PC + 1
 
 	instr := c.ReadMemory(c.PC)
-	op := instr >> 12
+	op := isa.Op(instr >> 12)
 	//fmt.Printf("Received Inst:0x%04x Op:%d\n", instr, op)
 
 	// process the current opcode
 	switch op {
-	case OpBR:
+	case isa.OpBR:
 		n := extract1C(instr, 11, 11) == 1
 		z := extract1C(instr, 10, 10) == 1
 		p := extract1C(instr, 9, 9) == 1
@@ -190,10 +232,10 @@ func (c *CPU) EmulateInstruction() (err error) {
 		if (n && c.CondRegister.N) || (z && c.CondRegister.Z) || (p && c.CondRegister.P) {
 			pc += PCoffset9
 		}
-	case OpJMP:
+	case isa.OpJMP:
 		baseR := extract1C(instr, 8, 6)
 		pc = c.Reg[baseR]
-	case OpADD:
+	case isa.OpADD:
 		dr := extract1C(instr, 11, 9)
 		sr1 := extract1C(instr, 8, 6)
 		bit5 := extract1C(instr, 5, 5)
@@ -207,7 +249,7 @@ func (c *CPU) EmulateInstruction() (err error) {
 			c.Reg[dr] = c.Reg[sr1] + c.Reg[sr2]
 		}
 		c.SetCC(dr)
-	case OpAND:
+	case isa.OpAND:
 		dr := extract1C(instr, 11, 9)
 		sr1 := extract1C(instr, 8, 6)
 		bit5 := extract1C(instr, 5, 5)
@@ -219,27 +261,31 @@ func (c *CPU) EmulateInstruction() (err error) {
 			c.Reg[dr] = c.Reg[sr1] & c.Reg[sr2]
 		}
 		c.SetCC(c.Reg[dr])
-	case OpNOT:
+	case isa.OpNOT:
 		dr := extract1C(instr, 11, 9)
 		sr := extract1C(instr, 8, 6)
 		c.Reg[dr] = ^c.Reg[sr]
 		c.SetCC(c.Reg[dr])
-	case OpLD:
+	case isa.OpLD:
 		dr := extract1C(instr, 11, 9)
 		PCoffset9 := extract2C(instr, 8, 0)
 		c.Reg[dr] = c.ReadMemory(c.PC + PCoffset9)
 		c.SetCC(c.Reg[dr])
 		log.Println(fmt.Sprintf("0x%04x: LD R%d,%d", c.PC, dr, PCoffset9))
-	case OpLDI:
+	case isa.OpLDI:
 		dr := extract1C(instr, 11, 9)
 		PCoffset9 := extract2C(instr, 8, 0)
 		c.Reg[dr] = c.ReadMemory(c.PC + PCoffset9)
 		c.SetCC(c.Reg[dr])
-	case OpST:
+	case isa.OpST:
+		sr := extract1C(instr, 11, 9)
+		PCoffset9 := extract2C(instr, 8, 0)
+		c.WriteMemory(c.PC+PCoffset9, c.Reg[sr])
+	case isa.OpSTI:
 		sr := extract1C(instr, 11, 9)
 		PCoffset9 := extract2C(instr, 8, 0)
 		c.WriteMemory(c.PC+PCoffset9, c.Reg[sr])
-	case OpJSR:
+	case isa.OpJSR:
 		bit11 := extract1C(instr, 11, 11)
 		c.Reg[7] = c.PC + 1
 		if bit11 == 1 {
@@ -249,76 +295,66 @@ func (c *CPU) EmulateInstruction() (err error) {
 			baseR := extract2C(instr, 8, 6)
 			pc = c.Reg[baseR]
 		}
-	case OpLDR:
+	case isa.OpLDR:
 		dr := extract1C(instr, 11, 9)
 		baseR := extract1C(instr, 8, 6)
 		offset6 := extract2C(instr, 5, 0)
 		c.Reg[dr] = c.ReadMemory(c.Reg[baseR] + offset6)
 		c.SetCC(c.Reg[dr])
-	case OpLEA:
+	case isa.OpLEA:
 		dr := extract1C(instr, 11, 9)
 		PCoffset9 := extract2C(instr, 8, 0)
 		c.Reg[dr] = c.PC + PCoffset9
 		c.SetCC(c.Reg[dr])
 		log.Println(fmt.Sprintf("0x%04x: LEA R%d,%d", c.PC, dr, PCoffset9))
-	case OpSTR:
+	case isa.OpSTR:
 		//sr := extract1C(instr, 11, 9)
 		//baseR := extract1C(instr, 8, 6)
 		offset6 := extract2C(instr, 5, 0)
 		c.WriteMemory(c.Reg[1]+offset6, c.Reg[0])
-	case OpTRAP:
-		trapCode := instr & 0xFF
+	case isa.OpTRAP:
+		trapCode := isa.Trap(instr & 0xFF)
 		switch trapCode {
-		case TrapGETC:
-			// read a single ASCII character
-			ascii, _, _ := getChar()
-			c.Reg[0] = uint16(ascii)
-		case TrapOUT:
-			//fmt.Println("trapout")
-			chr := rune(c.Reg[0])
-			fmt.Printf("%c", chr)
-			//fmt.Printf("%c\n", ch)
-		case TrapPUTS:
-			//c := c.Memory + c.Reg[0]
-			address := 0x3000 + c.Reg[0]
-			//log.Println("Puts: REG0 %d", address)
-			//uint16(c.Memory[address])
-			//c := c.ReadMemory(c.Reg[0])
-			log.Println(fmt.Sprintf("Address: 0x%04x", address))
-			foo := c.Memory[0x3000+165 : 0x3000+165+15]
-
-			for _, num := range foo {
-				fmt.Println("sum:", rune(num))
+		case isa.TrapGETC:
+			c.Reg[0] = c.readKeyboard()
+		case isa.TrapOUT:
+			c.WriteMemory(MemRegDDR, c.Reg[0])
+		case isa.TrapPUTS:
+			// print the NUL-terminated string of words starting at R0.
+			for addr := c.Reg[0]; ; addr++ {
+				chr := c.ReadMemory(addr)
+				if chr == 0 {
+					break
+				}
+				c.WriteMemory(MemRegDDR, chr)
 			}
-
-			var chr uint16
-			var i uint16
-			for ok := true; ok; ok = (chr != 0x0) {
-				//chr = c.ReadMemory(address + i)
-				chr = c.Memory[address+i]
-				fmt.Println("loop called chr: %d", chr)
-				fmt.Printf("XXX:%c", chr)
-				i++
+		case isa.TrapIN:
+			fmt.Print("Enter a character: ")
+			c.Reg[0] = c.readKeyboard()
+			c.WriteMemory(MemRegDDR, c.Reg[0])
+		case isa.TrapPUTSP:
+			// print the NUL-terminated string of packed bytes, two per
+			// word, low byte first.
+			for addr := c.Reg[0]; ; addr++ {
+				word := c.ReadMemory(addr)
+				lo := word & 0xFF
+				if lo == 0 {
+					break
+				}
+				c.WriteMemory(MemRegDDR, lo)
+				hi := word >> 8
+				if hi == 0 {
+					break
+				}
+				c.WriteMemory(MemRegDDR, hi)
 			}
-
-			fmt.Println("Block called")
-			os.Exit(1)
-			//for c > 0 {
-			//	chr := rune(c)
-			//	fmt.Printf("%c", chr)
-			//}
-			//for c {
-		//fmt.Printf("%s", c)
-		//	c++
-		//}
-		case TrapHALT:
-			log.Println("HALT")
-			os.Exit(1)
+		case isa.TrapHALT:
+			c.WriteMemory(MemRegMCR, 0x0000)
 		default:
 			log.Fatalf("Trap code not implemented: 0x%04X", instr)
 		}
-	case OpRES:
-	case OpRTI:
+	case isa.OpRES:
+	case isa.OpRTI:
 	default:
 		log.Fatalf("Bad Op Code received: 0x%04X", instr)
 	}
@@ -328,6 +364,16 @@ func (c *CPU) EmulateInstruction() (err error) {
 	return
 }
 
+// readKeyboard blocks until the Keyboard device reports data ready in
+// KBSR, then returns it via KBDR.
+func (c *CPU) readKeyboard() uint16 {
+	for {
+		if status := c.ReadMemory(MemRegKBSR); status&0x8000 != 0 {
+			return c.ReadMemory(MemRegKBDR)
+		}
+	}
+}
+
 func (c *CPU) SetCC(data uint16) {
 	c.CondRegister.N = isNegative(data)
 	c.CondRegister.Z = isZero(data)
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+// TestStepRecordsMCCWriteInOracle guards against the MCC increment in Step
+// bypassing WriteMemory (and therefore the oracle): StepWithProof's Merkle
+// proof is only valid if every memory mutation during the step, including
+// the housekeeping 0xFFFF increment, goes through WriteMemory. This must
+// hold for every InterpMode, since InterpBlocks increments MCC itself
+// instead of going through Step's shared bookkeeping.
+func TestStepRecordsMCCWriteInOracle(t *testing.T) {
+	for _, mode := range []InterpMode{InterpSwitch, InterpThreaded, InterpBlocks} {
+		t.Run(string(mode), func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Reset()
+			cpu.Memory[cpu.PC] = 0x5020 // AND R0, R0, #0
+			cpu.SetInterpMode(mode)
+
+			oracle := &AccessOracle{}
+			cpu.oracle = oracle
+			if err := cpu.Step(); err != nil {
+				t.Fatalf("Step: %v", err)
+			}
+			cpu.oracle = nil
+
+			for _, a := range oracle.Touched() {
+				if a.Addr == 0xFFFF && a.IsWrite {
+					return
+				}
+			}
+			t.Fatalf("oracle did not observe a write to 0xFFFF (MCC); touched = %+v", oracle.Touched())
+		})
+	}
+}
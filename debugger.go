@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sandeepkalra/go-lc3-vm/isa"
+)
+
+// Breakpoint stops Debugger.Continue when the CPU's PC reaches Addr.
+type Breakpoint struct {
+	Addr    uint16
+	Enabled bool
+}
+
+// AccessKind distinguishes a watchpoint firing on a memory read vs a write.
+type AccessKind int
+
+// Watchpoint access kinds.
+const (
+	AccessRead AccessKind = iota
+	AccessWrite
+)
+
+func (k AccessKind) String() string {
+	if k == AccessRead {
+		return "read"
+	}
+	return "write"
+}
+
+// Watchpoint stops Debugger.Continue when Addr is read or written,
+// depending on On.
+type Watchpoint struct {
+	Addr    uint16
+	On      AccessKind
+	Enabled bool
+}
+
+// Debugger wraps a CPU with delve-style process control: breakpoints,
+// watchpoints, single-stepping, step-over for JSR/TRAP, and continue.
+// It installs itself as the CPU's Oracle while stepping, so watchpoints
+// reuse the same memory-access hook StepWithProof uses for its witnesses.
+type Debugger struct {
+	CPU *CPU
+
+	breakpoints []Breakpoint
+	watchpoints []Watchpoint
+	symbols     map[string]uint16 // label -> address, loaded from a .sym file
+
+	stopped    bool
+	stopReason string
+}
+
+// NewDebugger wraps an already-Reset CPU for interactive control.
+func NewDebugger(cpu *CPU) *Debugger {
+	return &Debugger{CPU: cpu, symbols: map[string]uint16{}}
+}
+
+// OnRead implements Oracle: it fires read watchpoints.
+func (d *Debugger) OnRead(addr, val uint16) { d.checkWatch(addr, AccessRead) }
+
+// OnWrite implements Oracle: it fires write watchpoints.
+func (d *Debugger) OnWrite(addr, val uint16) { d.checkWatch(addr, AccessWrite) }
+
+// Touched implements Oracle. The debugger doesn't need a proof, so it keeps
+// no access log.
+func (d *Debugger) Touched() []MemAccess { return nil }
+
+func (d *Debugger) checkWatch(addr uint16, kind AccessKind) {
+	for _, w := range d.watchpoints {
+		if w.Enabled && w.Addr == addr && w.On == kind {
+			d.stopped = true
+			d.stopReason = fmt.Sprintf("watchpoint hit at 0x%04X (%s)", addr, kind)
+		}
+	}
+}
+
+func (d *Debugger) atBreakpoint() bool {
+	for _, b := range d.breakpoints {
+		if b.Enabled && b.Addr == d.CPU.PC {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBreakpoint arms a breakpoint at addr.
+func (d *Debugger) SetBreakpoint(addr uint16) {
+	d.breakpoints = append(d.breakpoints, Breakpoint{Addr: addr, Enabled: true})
+}
+
+// ClearBreakpoint removes the breakpoint at addr, if any.
+func (d *Debugger) ClearBreakpoint(addr uint16) {
+	for i, b := range d.breakpoints {
+		if b.Addr == addr {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetWatchpoint arms a watchpoint on addr for the given access kind.
+func (d *Debugger) SetWatchpoint(addr uint16, on AccessKind) {
+	d.watchpoints = append(d.watchpoints, Watchpoint{Addr: addr, On: on, Enabled: true})
+}
+
+// Step executes exactly one instruction, with watchpoints armed. It clears
+// any stopped/stopReason left over from an earlier watchpoint hit, so a
+// watchpoint firing once doesn't make every later Step/StepOver/Continue/
+// ContinueUntil call believe one fired on this step too.
+func (d *Debugger) Step() error {
+	d.stopped = false
+	d.stopReason = ""
+	// InterpBlocks' Step runs a whole cached block; step through the same
+	// decode cache one instruction at a time instead.
+	if d.CPU.interpMode == InterpBlocks {
+		d.CPU.interpMode = InterpThreaded
+		defer d.CPU.SetInterpMode(InterpBlocks)
+	}
+	d.CPU.oracle = d
+	err := d.CPU.Step()
+	d.CPU.oracle = nil
+	return err
+}
+
+// StepOver executes one instruction like Step, except that a JSR/JSRR/TRAP
+// runs to completion (PC returning to the address right after the call, via
+// R7) instead of stopping at its first instruction.
+func (d *Debugger) StepOver() error {
+	instr := d.CPU.Memory[d.CPU.PC]
+	op := isa.Op(instr >> 12)
+	if op != isa.OpJSR && op != isa.OpTRAP {
+		return d.Step()
+	}
+
+	returnAddr := d.CPU.PC + 1
+	for {
+		if err := d.Step(); err != nil {
+			return err
+		}
+		if d.CPU.PC == returnAddr || d.stopped || d.CPU.runState == RunStateStopped {
+			return nil
+		}
+	}
+}
+
+// Continue runs until a breakpoint or watchpoint fires, or the CPU halts.
+func (d *Debugger) Continue() error {
+	for {
+		if err := d.Step(); err != nil {
+			return err
+		}
+		if d.stopped {
+			return nil
+		}
+		if d.atBreakpoint() {
+			d.stopped = true
+			d.stopReason = fmt.Sprintf("breakpoint hit at 0x%04X", d.CPU.PC)
+			return nil
+		}
+		if d.CPU.runState == RunStateStopped {
+			return nil
+		}
+	}
+}
+
+// ContinueUntil runs until PC reaches addr, a breakpoint or watchpoint
+// fires, or the CPU halts.
+func (d *Debugger) ContinueUntil(addr uint16) error {
+	for {
+		if err := d.Step(); err != nil {
+			return err
+		}
+		if d.CPU.PC == addr || d.stopped || d.CPU.runState == RunStateStopped {
+			return nil
+		}
+	}
+}
+
+// LoadSymbols loads a .sym file produced by lc3asm -sym, so breakpoint
+// commands and disassembly can resolve labels.
+func (d *Debugger) LoadSymbols(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimPrefix(strings.TrimSpace(sc.Text()), "//")
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		addr, err := strconv.ParseUint(fields[1], 16, 16)
+		if err != nil {
+			continue
+		}
+		d.symbols[fields[0]] = uint16(addr)
+	}
+	return sc.Err()
+}
+
+// resolve turns a REPL operand into an address: a known label, or a bare
+// hex value with an optional "0x" prefix.
+func (d *Debugger) resolve(tok string) (uint16, error) {
+	if addr, ok := d.symbols[tok]; ok {
+		return addr, nil
+	}
+	tok = strings.TrimPrefix(strings.TrimPrefix(tok, "0x"), "0X")
+	v, err := strconv.ParseUint(tok, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown address or label %q", tok)
+	}
+	return uint16(v), nil
+}
+
+// REPL runs an interactive delve-style command loop against stdin/stdout:
+//
+//	(lc3dbg) b 0x3050      set a breakpoint
+//	(lc3dbg) watch 0x4000  set a write watchpoint
+//	(lc3dbg) s             single step
+//	(lc3dbg) so            step over (JSR/TRAP run to return)
+//	(lc3dbg) c             continue
+//	(lc3dbg) p R0          print a register
+//	(lc3dbg) x/16w 0x4000  examine memory
+//	(lc3dbg) disasm        disassemble around PC
+//	(lc3dbg) regs          dump all registers
+func (d *Debugger) REPL(in *bufio.Scanner, out *bufio.Writer) {
+	fmt.Fprint(out, "(lc3dbg) ")
+	out.Flush()
+	for in.Scan() {
+		d.runCommand(strings.Fields(in.Text()), out)
+		fmt.Fprint(out, "(lc3dbg) ")
+		out.Flush()
+	}
+}
+
+func (d *Debugger) runCommand(args []string, out *bufio.Writer) {
+	if len(args) == 0 {
+		return
+	}
+
+	if args[0] == "x" || strings.HasPrefix(args[0], "x/") {
+		d.examine(args, out)
+		return
+	}
+
+	switch args[0] {
+	case "b", "break":
+		if len(args) < 2 {
+			fmt.Fprintln(out, "usage: b <addr|label>")
+			return
+		}
+		addr, err := d.resolve(args[1])
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		d.SetBreakpoint(addr)
+		fmt.Fprintf(out, "breakpoint set at 0x%04X\n", addr)
+	case "watch":
+		if len(args) < 2 {
+			fmt.Fprintln(out, "usage: watch <addr|label> [r|w]")
+			return
+		}
+		addr, err := d.resolve(args[1])
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		kind := AccessWrite
+		if len(args) > 2 && args[2] == "r" {
+			kind = AccessRead
+		}
+		d.SetWatchpoint(addr, kind)
+		fmt.Fprintf(out, "%s watchpoint set at 0x%04X\n", kind, addr)
+	case "s", "step":
+		if err := d.Step(); err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		fmt.Fprintf(out, "0x%04X\n", d.CPU.PC)
+	case "so", "stepover":
+		if err := d.StepOver(); err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		fmt.Fprintf(out, "0x%04X\n", d.CPU.PC)
+	case "c", "continue":
+		if err := d.Continue(); err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		if d.stopReason != "" {
+			fmt.Fprintln(out, d.stopReason)
+		}
+		fmt.Fprintf(out, "0x%04X\n", d.CPU.PC)
+	case "p", "print":
+		if len(args) < 2 {
+			fmt.Fprintln(out, "usage: p <reg>")
+			return
+		}
+		d.printReg(args[1], out)
+	case "regs":
+		d.printRegs(out)
+	case "disasm":
+		d.disasm(out)
+	case "q", "quit":
+		os.Exit(0)
+	default:
+		fmt.Fprintf(out, "unknown command %q\n", args[0])
+	}
+}
+
+func regIndex(tok string) (int, error) {
+	tok = strings.ToUpper(tok)
+	if len(tok) == 2 && tok[0] == 'R' && tok[1] >= '0' && tok[1] <= '7' {
+		return int(tok[1] - '0'), nil
+	}
+	return 0, fmt.Errorf("not a register: %q", tok)
+}
+
+func (d *Debugger) printReg(tok string, out *bufio.Writer) {
+	if strings.EqualFold(tok, "PC") {
+		fmt.Fprintf(out, "PC = 0x%04X\n", d.CPU.PC)
+		return
+	}
+	n, err := regIndex(tok)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+	fmt.Fprintf(out, "R%d = 0x%04X\n", n, d.CPU.Reg[n])
+}
+
+func (d *Debugger) printRegs(out *bufio.Writer) {
+	for i, v := range d.CPU.Reg {
+		fmt.Fprintf(out, "R%d = 0x%04X  ", i, v)
+	}
+	fmt.Fprintf(out, "\nPC = 0x%04X\n", d.CPU.PC)
+}
+
+func (d *Debugger) examine(args []string, out *bufio.Writer) {
+	if len(args) < 2 {
+		fmt.Fprintln(out, "usage: x/<n>w <addr|label>")
+		return
+	}
+	spec := strings.TrimSuffix(strings.TrimPrefix(args[0], "x/"), "w")
+	n, err := strconv.Atoi(spec)
+	if err != nil || n <= 0 {
+		n = 1
+	}
+	addr, err := d.resolve(args[1])
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+	for i := 0; i < n; i++ {
+		a := addr + uint16(i)
+		fmt.Fprintf(out, "0x%04X: 0x%04X\n", a, d.CPU.Memory[a])
+	}
+}
+
+// disasm prints a small window of raw opcode mnemonics around PC. It is
+// deliberately minimal; a full disassembler belongs to lc3asm's symbol
+// table and isa.Mnemonics, not duplicated here.
+func (d *Debugger) disasm(out *bufio.Writer) {
+	for i := int32(-2); i <= 2; i++ {
+		addr := uint16(int32(d.CPU.PC) + i)
+		instr := d.CPU.Memory[addr]
+		marker := "  "
+		if addr == d.CPU.PC {
+			marker = "=>"
+		}
+		fmt.Fprintf(out, "%s 0x%04X: %-4s (0x%04X)\n", marker, addr, isa.Mnemonics[isa.Op(instr>>12)], instr)
+	}
+}
+
+// RPCService exposes Debugger control over JSON-RPC, so an external tool
+// (an editor plugin, a test harness) can drive it the same way the REPL
+// does, without scraping terminal output.
+type RPCService struct {
+	dbg *Debugger
+}
+
+// BreakpointArgs names an address for SetBreakpoint/ClearBreakpoint.
+type BreakpointArgs struct {
+	Addr uint16
+}
+
+// StepReply reports the PC after a Step/StepOver/Continue call.
+type StepReply struct {
+	PC         uint16
+	StopReason string
+}
+
+// RegsReply reports the full register file and PC.
+type RegsReply struct {
+	Reg [8]uint16
+	PC  uint16
+}
+
+// SetBreakpoint is the RPC-exposed form of Debugger.SetBreakpoint.
+func (s *RPCService) SetBreakpoint(args *BreakpointArgs, reply *struct{}) error {
+	s.dbg.SetBreakpoint(args.Addr)
+	return nil
+}
+
+// Step is the RPC-exposed form of Debugger.Step.
+func (s *RPCService) Step(args *struct{}, reply *StepReply) error {
+	if err := s.dbg.Step(); err != nil {
+		return err
+	}
+	reply.PC = s.dbg.CPU.PC
+	return nil
+}
+
+// Continue is the RPC-exposed form of Debugger.Continue.
+func (s *RPCService) Continue(args *struct{}, reply *StepReply) error {
+	if err := s.dbg.Continue(); err != nil {
+		return err
+	}
+	reply.PC = s.dbg.CPU.PC
+	reply.StopReason = s.dbg.stopReason
+	return nil
+}
+
+// Regs is the RPC-exposed form of reading the register file.
+func (s *RPCService) Regs(args *struct{}, reply *RegsReply) error {
+	reply.Reg = s.dbg.CPU.Reg
+	reply.PC = s.dbg.CPU.PC
+	return nil
+}
+
+// ServeRPC starts a JSON-RPC server for this Debugger on addr (e.g.
+// ":4224"), blocking until the listener errors.
+func (d *Debugger) ServeRPC(addr string) error {
+	if err := rpc.Register(&RPCService{dbg: d}); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go jsonrpc.ServeConn(conn)
+	}
+}
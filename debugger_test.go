@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestStepOverClearsStaleStoppedFlag guards against a watchpoint hit in an
+// earlier call permanently latching Debugger.stopped: StepOver (and
+// Continue/ContinueUntil) must only stop early on a watchpoint that fires
+// during *this* call, not one left over from a previous session.
+func TestStepOverClearsStaleStoppedFlag(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Reset()
+	cpu.Memory[0x3000] = 0x480E // JSR #14 -> 0x3010
+	cpu.Memory[0x3010] = 0xC1C0 // RET (JMP R7)
+
+	dbg := NewDebugger(cpu)
+	dbg.stopped = true // simulate a watchpoint that fired in an earlier call
+	dbg.stopReason = "watchpoint hit at 0x1234 (write)"
+
+	returnAddr := cpu.PC + 1
+	if err := dbg.StepOver(); err != nil {
+		t.Fatalf("StepOver: %v", err)
+	}
+
+	if cpu.PC != returnAddr {
+		t.Fatalf("PC = 0x%04X after StepOver, want 0x%04X (stale stopped flag cut it short)", cpu.PC, returnAddr)
+	}
+}
+
+// TestRunCommandDispatchesExamine guards against the documented "x/<n>w
+// <addr>" REPL command being unreachable: runCommand used to switch on an
+// exact match of args[0] == "x", but "x/16w 0x4000" tokenizes with
+// args[0] == "x/16w" and fell through to "unknown command".
+func TestRunCommandDispatchesExamine(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Reset()
+	cpu.Memory[0x4000] = 0xBEEF
+
+	dbg := NewDebugger(cpu)
+	var buf bytes.Buffer
+	out := bufio.NewWriter(&buf)
+	dbg.runCommand(strings.Fields("x/1w 0x4000"), out)
+	out.Flush()
+
+	if got := buf.String(); !strings.Contains(got, "0xBEEF") {
+		t.Fatalf("runCommand(x/1w 0x4000) output = %q, want it to contain memory at 0x4000 (0xBEEF)", got)
+	}
+}
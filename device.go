@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Device is a memory-mapped peripheral attached to a Bus. Read/Write return
+// false when addr isn't one of theirs, so the Bus can try the next Device;
+// Tick lets a Device advance internal state (e.g. a Timer counting down)
+// once per executed instruction.
+type Device interface {
+	Read(addr uint16) (uint16, bool)
+	Write(addr, val uint16) bool
+	Tick(cycles uint64)
+}
+
+// Bus dispatches memory-mapped reads and writes to its registered Devices.
+// A CPU consults its Bus before falling through to its raw Memory array, so
+// new peripherals (a framebuffer, a network socket) can be plugged in
+// without touching the trap switch in EmulateInstruction.
+type Bus struct {
+	devices []Device
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Register attaches a Device to the Bus.
+func (b *Bus) Register(d Device) {
+	b.devices = append(b.devices, d)
+}
+
+// Read asks each registered Device in turn whether it claims addr.
+func (b *Bus) Read(addr uint16) (uint16, bool) {
+	for _, d := range b.devices {
+		if val, ok := d.Read(addr); ok {
+			return val, true
+		}
+	}
+	return 0, false
+}
+
+// Write asks each registered Device in turn whether it claims addr.
+func (b *Bus) Write(addr, val uint16) bool {
+	for _, d := range b.devices {
+		if d.Write(addr, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tick advances every registered Device by cycles.
+func (b *Bus) Tick(cycles uint64) {
+	for _, d := range b.devices {
+		d.Tick(cycles)
+	}
+}
+
+// Keyboard implements the KBSR/KBDR memory-mapped registers, reading
+// characters from In (os.Stdin by default). Polling calls In.Read directly,
+// so it blocks whenever In has no byte ready.
+type Keyboard struct {
+	In io.Reader
+
+	pending uint16
+	ready   bool
+}
+
+// NewKeyboard creates a Keyboard reading from in.
+func NewKeyboard(in io.Reader) *Keyboard {
+	return &Keyboard{In: in}
+}
+
+// Read implements Device.
+func (k *Keyboard) Read(addr uint16) (uint16, bool) {
+	switch addr {
+	case MemRegKBSR:
+		k.poll()
+		if k.ready {
+			return 0x8000, true
+		}
+		return 0, true
+	case MemRegKBDR:
+		k.poll()
+		if !k.ready {
+			return 0, true
+		}
+		val := k.pending
+		k.ready = false
+		return val, true
+	default:
+		return 0, false
+	}
+}
+
+// Write implements Device. KBSR/KBDR are read-only; writes are accepted
+// and ignored, like the real LC-3 hardware registers.
+func (k *Keyboard) Write(addr, val uint16) bool {
+	return addr == MemRegKBSR || addr == MemRegKBDR
+}
+
+// Tick implements Device. The keyboard has no time-driven state.
+func (k *Keyboard) Tick(cycles uint64) {}
+
+// poll reads one byte from In, latching it until TrapGETC/TrapIN consumes
+// it via KBDR. The read is a plain In.Read call and blocks until In yields
+// a byte or an error; on an error nothing is latched.
+func (k *Keyboard) poll() {
+	if k.ready || k.In == nil {
+		return
+	}
+	var b [1]byte
+	if n, err := k.In.Read(b[:]); err == nil && n == 1 {
+		k.pending = uint16(b[0])
+		k.ready = true
+	}
+}
+
+// Display memory-mapped registers, per the LC-3 spec.
+const (
+	MemRegDSR uint16 = 0xFE04 // Display status
+	MemRegDDR uint16 = 0xFE06 // Display data
+)
+
+// Display implements the DSR/DDR memory-mapped registers, writing
+// characters to Out (os.Stdout by default) as they're punched through DDR.
+type Display struct {
+	Out io.Writer
+}
+
+// NewDisplay creates a Display writing to out.
+func NewDisplay(out io.Writer) *Display {
+	return &Display{Out: out}
+}
+
+// Read implements Device.
+func (d *Display) Read(addr uint16) (uint16, bool) {
+	switch addr {
+	case MemRegDSR:
+		return 0x8000, true // always ready to accept another character
+	case MemRegDDR:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// Write implements Device.
+func (d *Display) Write(addr, val uint16) bool {
+	switch addr {
+	case MemRegDSR:
+		return true
+	case MemRegDDR:
+		fmt.Fprintf(d.Out, "%c", rune(val))
+		return true
+	default:
+		return false
+	}
+}
+
+// Tick implements Device. The display has no time-driven state.
+func (d *Display) Tick(cycles uint64) {}
+
+// MemRegMCR is the Machine Control Register. Writing a value with bit 15
+// clear halts the machine; this replaces the previous os.Exit(1) TrapHALT
+// path with the real LC-3 shutdown mechanism.
+const MemRegMCR uint16 = 0xFFFE
+
+// MachineControl implements the MCR memory-mapped register.
+type MachineControl struct {
+	onHalt func()
+	value  uint16
+}
+
+// NewMachineControl creates a MachineControl that calls onHalt once MCR's
+// run bit is cleared.
+func NewMachineControl(onHalt func()) *MachineControl {
+	return &MachineControl{onHalt: onHalt, value: 0x8000}
+}
+
+// Read implements Device.
+func (m *MachineControl) Read(addr uint16) (uint16, bool) {
+	if addr == MemRegMCR {
+		return m.value, true
+	}
+	return 0, false
+}
+
+// Write implements Device.
+func (m *MachineControl) Write(addr, val uint16) bool {
+	if addr != MemRegMCR {
+		return false
+	}
+	m.value = val
+	if m.value&0x8000 == 0 && m.onHalt != nil {
+		m.onHalt()
+	}
+	return true
+}
+
+// Tick implements Device. MCR has no time-driven state.
+func (m *MachineControl) Tick(cycles uint64) {}
+
+// Timer memory-mapped registers. These are specific to this emulator, not
+// the LC-3 spec: TimerCR arms the timer for N Tick calls, TimerSR latches
+// to 1 once the count reaches zero.
+const (
+	MemRegTimerCR uint16 = 0xFE08
+	MemRegTimerSR uint16 = 0xFE0A
+)
+
+// Timer replaces the previously unused CPU.TimerStarted/TimerStart fields
+// with a real memory-mapped countdown timer, ticked once per executed
+// instruction.
+type Timer struct {
+	remaining uint16
+	expired   bool
+}
+
+// NewTimer creates a disarmed Timer.
+func NewTimer() *Timer {
+	return &Timer{}
+}
+
+// Read implements Device.
+func (t *Timer) Read(addr uint16) (uint16, bool) {
+	switch addr {
+	case MemRegTimerCR:
+		return t.remaining, true
+	case MemRegTimerSR:
+		if t.expired {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// Write implements Device.
+func (t *Timer) Write(addr, val uint16) bool {
+	switch addr {
+	case MemRegTimerCR:
+		t.remaining = val
+		t.expired = false
+		return true
+	case MemRegTimerSR:
+		t.expired = val != 0
+		return true
+	default:
+		return false
+	}
+}
+
+// Tick implements Device.
+func (t *Timer) Tick(cycles uint64) {
+	if t.remaining == 0 {
+		return
+	}
+	if uint64(t.remaining) <= cycles {
+		t.remaining = 0
+		t.expired = true
+		return
+	}
+	t.remaining -= uint16(cycles)
+}
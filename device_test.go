@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeyboardLatchesUntilRead(t *testing.T) {
+	k := NewKeyboard(strings.NewReader("A"))
+
+	status, _ := k.Read(MemRegKBSR)
+	if status != 0x8000 {
+		t.Fatalf("KBSR = 0x%04X before KBDR read, want 0x8000 (ready)", status)
+	}
+
+	data, _ := k.Read(MemRegKBDR)
+	if data != 'A' {
+		t.Fatalf("KBDR = 0x%04X, want 'A'", data)
+	}
+
+	status, _ = k.Read(MemRegKBSR)
+	if status != 0 {
+		t.Fatalf("KBSR = 0x%04X after KBDR drained it, want 0 (not ready)", status)
+	}
+}
+
+func TestDisplayWritesDDRToOut(t *testing.T) {
+	var buf bytes.Buffer
+	d := NewDisplay(&buf)
+
+	if ok := d.Write(MemRegDDR, uint16('X')); !ok {
+		t.Fatalf("Write(DDR) returned false")
+	}
+	if got := buf.String(); got != "X" {
+		t.Fatalf("Out = %q, want %q", got, "X")
+	}
+}
+
+func TestMachineControlInvokesOnHaltWhenRunBitCleared(t *testing.T) {
+	halted := false
+	mc := NewMachineControl(func() { halted = true })
+
+	mc.Write(MemRegMCR, 0x0000)
+	if !halted {
+		t.Fatalf("onHalt not called after clearing MCR's run bit")
+	}
+}
+
+func TestBusDispatchesToFirstClaimingDevice(t *testing.T) {
+	bus := NewBus()
+	bus.Register(NewKeyboard(strings.NewReader("")))
+	bus.Register(NewMachineControl(func() {}))
+
+	if _, ok := bus.Read(MemRegMCR); !ok {
+		t.Fatalf("Bus.Read(MCR) = not ok, want the MachineControl device to claim it")
+	}
+	if _, ok := bus.Read(0x1234); ok {
+		t.Fatalf("Bus.Read(0x1234) = ok, want no device to claim an address none of them own")
+	}
+}
+
+func TestTimerExpiresAfterArmedCycles(t *testing.T) {
+	timer := NewTimer()
+	timer.Write(MemRegTimerCR, 2)
+
+	timer.Tick(1)
+	if sr, _ := timer.Read(MemRegTimerSR); sr != 0 {
+		t.Fatalf("TimerSR = %d after 1 of 2 cycles, want 0 (not expired)", sr)
+	}
+
+	timer.Tick(1)
+	if sr, _ := timer.Read(MemRegTimerSR); sr != 1 {
+		t.Fatalf("TimerSR = %d after 2 of 2 cycles, want 1 (expired)", sr)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+// zeroKeyboardReader always yields a single 0x00 byte immediately, so a
+// benchmark run that hits TRAP GETC/IN never blocks on real stdin input.
+type zeroKeyboardReader struct{}
+
+func (zeroKeyboardReader) Read(p []byte) (int, error) {
+	p[0] = 0
+	return 1, nil
+}
+
+// benchmarkInterp drives the 2048 ROM under the given InterpMode for b.N
+// steps, resetting the CPU (but keeping its decode/block caches) whenever
+// TRAP HALT stops it, so every mode gets a fair, uninterrupted step count.
+func benchmarkInterp(b *testing.B, mode InterpMode) {
+	mem, err := RetrieveROM("rom/2048.obj")
+	if err != nil {
+		b.Skipf("rom/2048.obj not present in this checkout: %v", err)
+	}
+
+	cpu := NewCPU()
+	cpu.Bus = NewBus()
+	cpu.Bus.Register(NewKeyboard(zeroKeyboardReader{}))
+	cpu.Bus.Register(NewDisplay(io.Discard))
+	cpu.Bus.Register(NewMachineControl(func() { cpu.Stop() }))
+	cpu.Bus.Register(NewTimer())
+	cpu.Memory = mem
+	cpu.Reset()
+	cpu.SetInterpMode(mode)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cpu.Step(); err != nil {
+			b.Fatalf("Step: %v", err)
+		}
+		if cpu.runState == RunStateStopped {
+			cpu.Reset()
+		}
+	}
+}
+
+// BenchmarkInterpSwitch exercises the baseline re-decode-every-time path.
+func BenchmarkInterpSwitch(b *testing.B) { benchmarkInterp(b, InterpSwitch) }
+
+// BenchmarkInterpThreaded exercises the per-address decoded-instruction
+// cache, expected to be several times faster than InterpSwitch once the
+// cache is warm.
+func BenchmarkInterpThreaded(b *testing.B) { benchmarkInterp(b, InterpThreaded) }
+
+// BenchmarkInterpBlocks exercises the basic-block-threaded cache.
+func BenchmarkInterpBlocks(b *testing.B) { benchmarkInterp(b, InterpBlocks) }
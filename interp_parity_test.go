@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+// TestBlockModeUsesPerInstructionPC guards against stepBlock computing every
+// instruction's PC-relative effective address from the block's start
+// address instead of its own address: a block of two LD instructions back
+// to back must resolve each one relative to its own PC, not the first.
+func TestBlockModeUsesPerInstructionPC(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Reset()
+	cpu.SetInterpMode(InterpBlocks)
+
+	cpu.Memory[0x3000] = 0x2005 // LD R0, #5  -> effective addr 0x3005
+	cpu.Memory[0x3001] = 0x2205 // LD R1, #5  -> effective addr 0x3006
+	cpu.Memory[0x3002] = 0x0000 // BR (never taken), ends the block
+	cpu.Memory[0x3005] = 0xAAAA
+	cpu.Memory[0x3006] = 0xBBBB
+
+	// stepBlock runs the whole cached block (LD, LD, BR) in a single Step
+	// call, since BR is the first branchy instruction it encounters.
+	if err := cpu.Step(); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+
+	if cpu.Reg[0] != 0xAAAA {
+		t.Errorf("R0 = 0x%04X, want 0xAAAA", cpu.Reg[0])
+	}
+	if cpu.Reg[1] != 0xBBBB {
+		t.Errorf("R1 = 0x%04X, want 0xBBBB", cpu.Reg[1])
+	}
+}
+
+// TestSTIParityAcrossInterpModes guards against InterpSwitch's
+// EmulateInstruction falling out of sync with the decoded-instruction
+// handlers in interp.go: execSTI has existed in the threaded/blocks paths
+// since InterpMode was introduced, but the default InterpSwitch mode must
+// handle every opcode those paths do, or switching -interp changes what
+// programs can run.
+func TestSTIParityAcrossInterpModes(t *testing.T) {
+	const storeAddr = 0x3003
+
+	for _, mode := range []InterpMode{InterpSwitch, InterpThreaded, InterpBlocks} {
+		t.Run(string(mode), func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Reset()
+			cpu.Reg[0] = 0x1234
+			cpu.Memory[cpu.PC] = 0xB003 // STI R0, #3
+			cpu.SetInterpMode(mode)
+
+			if err := cpu.Step(); err != nil {
+				t.Fatalf("Step: %v", err)
+			}
+
+			if got := cpu.Memory[storeAddr]; got != 0x1234 {
+				t.Fatalf("Memory[0x%04X] = 0x%04X, want 0x1234", storeAddr, got)
+			}
+		})
+	}
+}
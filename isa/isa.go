@@ -0,0 +1,82 @@
+// Package isa holds the LC-3 instruction and trap encodings shared by the
+// lc3-vm interpreter and the lc3asm assembler, so the two stay in lockstep.
+package isa
+
+// Op identifies one of the 16 LC-3 opcodes: the top 4 bits of an
+// instruction word.
+type Op uint16
+
+// List of OpCodes
+const (
+	OpBR   Op = iota // branch
+	OpADD            // add
+	OpLD             // load
+	OpST             // store
+	OpJSR            // jump register
+	OpAND            // bitwise and
+	OpLDR            // load register
+	OpSTR            // store register
+	OpRTI            // unused
+	OpNOT            // bitwise not
+	OpLDI            // load indirect
+	OpSTI            // store indirect
+	OpJMP            // jump
+	OpRES            // reserved (unused)
+	OpLEA            // load effective address
+	OpTRAP           // execute trap
+)
+
+// Trap identifies one of the service routines invoked by OpTRAP.
+type Trap uint16
+
+// List of Trap codes
+const (
+	TrapGETC  Trap = 0x20 // get character from keyboard
+	TrapOUT   Trap = 0x21 // output a character
+	TrapPUTS  Trap = 0x22 // output a word string
+	TrapIN    Trap = 0x23 // input a character, echoed
+	TrapPUTSP Trap = 0x24 // output a byte string
+	TrapHALT  Trap = 0x25 // halt the program
+)
+
+// Mnemonics maps each Op to its assembly mnemonic, shared by the assembler
+// and any future disassembler.
+var Mnemonics = map[Op]string{
+	OpBR:   "BR",
+	OpADD:  "ADD",
+	OpLD:   "LD",
+	OpST:   "ST",
+	OpJSR:  "JSR",
+	OpAND:  "AND",
+	OpLDR:  "LDR",
+	OpSTR:  "STR",
+	OpRTI:  "RTI",
+	OpNOT:  "NOT",
+	OpLDI:  "LDI",
+	OpSTI:  "STI",
+	OpJMP:  "JMP",
+	OpRES:  "RES",
+	OpLEA:  "LEA",
+	OpTRAP: "TRAP",
+}
+
+// TrapAliases maps the named trap aliases the assembler accepts in place of
+// a raw TRAP vector (e.g. "OUT" instead of "TRAP x21").
+var TrapAliases = map[string]Trap{
+	"GETC":  TrapGETC,
+	"OUT":   TrapOUT,
+	"PUTS":  TrapPUTS,
+	"IN":    TrapIN,
+	"PUTSP": TrapPUTSP,
+	"HALT":  TrapHALT,
+}
+
+// Field widths, in bits, of the signed immediates and PC-relative offsets
+// used by each addressing mode. The assembler checks a resolved value fits
+// before encoding it.
+const (
+	PCOffset9Bits  = 9  // BR, LD, LDI, LEA, ST, STI
+	PCOffset11Bits = 11 // JSR
+	Offset6Bits    = 6  // LDR, STR
+	Imm5Bits       = 5  // ADD, AND immediate mode
+)
@@ -0,0 +1,28 @@
+package isa
+
+import "testing"
+
+// TestMnemonicsCoversEveryOp guards against an Op being added to the opcode
+// const block without a matching Mnemonics entry, which would make it
+// unparseable by lc3asm and undisassemblable by the debugger.
+func TestMnemonicsCoversEveryOp(t *testing.T) {
+	for op := OpBR; op <= OpTRAP; op++ {
+		if _, ok := Mnemonics[op]; !ok {
+			t.Errorf("Op %d has no Mnemonics entry", op)
+		}
+	}
+}
+
+// TestTrapAliasesResolveToKnownTraps guards against a typo in TrapAliases
+// pointing at a Trap value no TRAP handler implements.
+func TestTrapAliasesResolveToKnownTraps(t *testing.T) {
+	known := map[Trap]bool{
+		TrapGETC: true, TrapOUT: true, TrapPUTS: true,
+		TrapIN: true, TrapPUTSP: true, TrapHALT: true,
+	}
+	for name, trap := range TrapAliases {
+		if !known[trap] {
+			t.Errorf("TrapAliases[%q] = 0x%02X, not a known Trap", name, trap)
+		}
+	}
+}
@@ -1,14 +1,27 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"os"
 )
 
+var (
+	romPath    = flag.String("rom", "rom/2048.obj", "path to the .obj ROM to load")
+	proofAt    = flag.Int("proof-at", -1, "emit a StepWithProof proof JSON for the step at this index (-1 disables)")
+	snapshotAt = flag.Int("snapshot-at", 0, "write a State snapshot to disk every N steps (0 disables)")
+	debugFlag  = flag.Bool("debug", false, "enter the interactive lc3dbg REPL instead of running freely")
+	symPath    = flag.String("sym", "", "path to a .sym file (from lc3asm -sym) to resolve labels in the debugger")
+	rpcAddr    = flag.String("rpc", "", "serve a JSON-RPC lc3dbg service on this address instead of the REPL (e.g. :4224)")
+	interp     = flag.String("interp", string(InterpSwitch), "interpreter dispatch strategy: switch|threaded|blocks")
+)
+
 func main() {
 	// we need a parallel OS thread to avoid audio stuttering
 	//runtime.GOMAXPROCS(2)
@@ -16,12 +29,13 @@ func main() {
 	// we need to keep OpenGL calls on a single thread
 	//runtime.LockOSThread()
 
+	flag.Parse()
+
 	log.Printf("Starting LC3-VM")
-	path := "rom/2048.obj"
 
 	// load the ROM file
 	// read the rom file into a buffer
-	mem, err := RetrieveROM(path)
+	mem, err := RetrieveROM(*romPath)
 	if err != nil {
 		//return nil, err
 		panic(err)
@@ -32,11 +46,83 @@ func main() {
 	cpu := NewCPU()
 	cpu.Memory = mem
 	cpu.Reset()
-	cpu.Run()
+
+	switch InterpMode(*interp) {
+	case InterpSwitch, InterpThreaded, InterpBlocks:
+		cpu.SetInterpMode(InterpMode(*interp))
+	default:
+		log.Fatalf("unknown -interp mode %q (want switch, threaded, or blocks)", *interp)
+	}
+
+	switch {
+	case *debugFlag || *rpcAddr != "":
+		dbg := NewDebugger(cpu)
+		if *symPath != "" {
+			if err := dbg.LoadSymbols(*symPath); err != nil {
+				log.Printf("LoadSymbols: %v", err)
+			}
+		}
+		if *rpcAddr != "" {
+			log.Printf("serving lc3dbg JSON-RPC on %s", *rpcAddr)
+			if err := dbg.ServeRPC(*rpcAddr); err != nil {
+				log.Fatalf("ServeRPC: %v", err)
+			}
+		} else {
+			dbg.REPL(bufio.NewScanner(os.Stdin), bufio.NewWriter(os.Stdout))
+		}
+	case *proofAt >= 0 || *snapshotAt > 0:
+		runInstrumented(cpu)
+	default:
+		cpu.Run()
+	}
 
 	fmt.Println("Exiting")
 }
 
+// runInstrumented drives the CPU one Step at a time so it can, in addition
+// to normal execution, periodically dump State snapshots to disk and emit a
+// step proof at a requested step index.
+func runInstrumented(cpu *CPU) {
+	for step := 0; ; step++ {
+		if *snapshotAt > 0 && step%*snapshotAt == 0 {
+			writeSnapshot(cpu, step)
+		}
+
+		if step == *proofAt {
+			pre, post, proof := cpu.StepWithProof(&AccessOracle{})
+			writeProof(step, pre, post, proof)
+		} else if err := cpu.Step(); err != nil {
+			log.Printf("step %d: %v", step, err)
+			break
+		}
+
+		if cpu.runState == RunStateStopped {
+			break
+		}
+	}
+}
+
+func writeSnapshot(cpu *CPU, step int) {
+	name := fmt.Sprintf("snapshot-%d.json", step)
+	data, err := json.Marshal(cpu.CaptureState())
+	if err != nil {
+		log.Printf("writeSnapshot: marshal step %d: %v", step, err)
+		return
+	}
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		log.Printf("writeSnapshot: write step %d: %v", step, err)
+	}
+}
+
+func writeProof(step int, preHash, postHash [32]byte, proof []byte) {
+	name := fmt.Sprintf("proof-%d.json", step)
+	if err := os.WriteFile(name, proof, 0644); err != nil {
+		log.Printf("writeProof: write step %d: %v", step, err)
+		return
+	}
+	log.Printf("proof-at step %d: pre=%x post=%x -> %s", step, preHash, postHash, name)
+}
+
 func RetrieveROM(filename string) ([65536]uint16, error) {
 	m := [65536]uint16{}
 
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+)
+
+// merkleDepth is the depth of the binary Merkle tree computed over CPU
+// memory: 65536 = 2^16 words, one leaf per address.
+const merkleDepth = 16
+
+// State is the canonical, serializable snapshot of the register file, PC,
+// condition flags, the machine control count at 0xFFFF, and a Merkle root
+// committing to all of memory. Two CPUs with identical State are guaranteed
+// to execute identically only so long as neither has a Bus wired up: State
+// does not capture device-internal state (Keyboard.pending/ready,
+// Timer.remaining/expired, MachineControl.value), so a CPU with devices
+// attached can diverge from a State-identical one with no devices, or with
+// devices in a different internal state, even though their hashes match.
+type State struct {
+	Reg     [8]uint16 `json:"reg"`
+	PC      uint16    `json:"pc"`
+	Cond    uint8     `json:"cond"` // bit 2: N, bit 1: Z, bit 0: P
+	MCC     uint16    `json:"mcc"`
+	MemRoot [32]byte  `json:"memRoot"`
+}
+
+// Hash returns the keccak-free, sha256 commitment to State. It is the value
+// external verifiers compare against when replaying a single proved step.
+func (s State) Hash() [32]byte {
+	buf := make([]byte, 0, len(s.Reg)*2+2+1+2+len(s.MemRoot))
+	for _, r := range s.Reg {
+		buf = append(buf, byte(r>>8), byte(r))
+	}
+	buf = append(buf, byte(s.PC>>8), byte(s.PC))
+	buf = append(buf, s.Cond)
+	buf = append(buf, byte(s.MCC>>8), byte(s.MCC))
+	buf = append(buf, s.MemRoot[:]...)
+	return sha256.Sum256(buf)
+}
+
+// CaptureState snapshots the CPU's current State, including a fresh Merkle
+// root over the full memory image.
+func (c *CPU) CaptureState() State {
+	s := State{
+		Reg: c.Reg,
+		PC:  c.PC,
+		MCC: c.Memory[0xFFFF],
+	}
+	if c.CondRegister != nil {
+		if c.CondRegister.N {
+			s.Cond |= 1 << 2
+		}
+		if c.CondRegister.Z {
+			s.Cond |= 1 << 1
+		}
+		if c.CondRegister.P {
+			s.Cond |= 1 << 0
+		}
+	}
+	s.MemRoot = newMemoryMerkleTree(&c.Memory).root
+	return s
+}
+
+// memoryMerkleTree is a fixed binary Merkle tree of depth merkleDepth built
+// over the 65536 memory words, one leaf hash per address.
+type memoryMerkleTree struct {
+	levels [][][32]byte // levels[0] is the leaves, levels[len-1] is {root}
+	root   [32]byte
+}
+
+func newMemoryMerkleTree(mem *[65536]uint16) *memoryMerkleTree {
+	leaves := make([][32]byte, len(mem))
+	for i, word := range mem {
+		var b [2]byte
+		b[0] = byte(word >> 8)
+		b[1] = byte(word)
+		leaves[i] = sha256.Sum256(b[:])
+	}
+
+	levels := [][][32]byte{leaves}
+	cur := leaves
+	for len(cur) > 1 {
+		next := make([][32]byte, len(cur)/2)
+		for i := range next {
+			var buf [64]byte
+			copy(buf[:32], cur[2*i][:])
+			copy(buf[32:], cur[2*i+1][:])
+			next[i] = sha256.Sum256(buf[:])
+		}
+		levels = append(levels, next)
+		cur = next
+	}
+
+	return &memoryMerkleTree{levels: levels, root: cur[0]}
+}
+
+// siblingPath returns the merkleDepth sibling hashes needed to recompute the
+// root from a single leaf at addr.
+func (t *memoryMerkleTree) siblingPath(addr uint16) [][32]byte {
+	path := make([][32]byte, 0, merkleDepth)
+	idx := int(addr)
+	for lvl := 0; lvl < len(t.levels)-1; lvl++ {
+		path = append(path, t.levels[lvl][idx^1])
+		idx /= 2
+	}
+	return path
+}
+
+// MemAccess records a single memory read or write observed by an Oracle
+// during a proved step.
+type MemAccess struct {
+	Addr    uint16 `json:"addr"`
+	Val     uint16 `json:"val"`
+	IsWrite bool   `json:"isWrite"`
+}
+
+// Oracle observes the memory accesses made during one CPU.Step so that
+// StepWithProof can build a proof containing only the words actually
+// touched, instead of the full 64K-word memory image.
+type Oracle interface {
+	OnRead(addr, val uint16)
+	OnWrite(addr, val uint16)
+	Touched() []MemAccess
+}
+
+// AccessOracle is the default Oracle: it just records every access in order.
+type AccessOracle struct {
+	touched []MemAccess
+}
+
+// OnRead implements Oracle.
+func (o *AccessOracle) OnRead(addr, val uint16) {
+	o.touched = append(o.touched, MemAccess{Addr: addr, Val: val})
+}
+
+// OnWrite implements Oracle.
+func (o *AccessOracle) OnWrite(addr, val uint16) {
+	o.touched = append(o.touched, MemAccess{Addr: addr, Val: val, IsWrite: true})
+}
+
+// Touched implements Oracle.
+func (o *AccessOracle) Touched() []MemAccess {
+	return o.touched
+}
+
+// WordProof is the witness for a single touched memory word: its value plus
+// the Merkle sibling path needed to verify it against a memory root.
+type WordProof struct {
+	MemAccess
+	Siblings [][32]byte `json:"siblings"`
+}
+
+// Proof is the serialized evidence for one proved step: the pre- and
+// post-state, and the touched memory words with their Merkle sibling paths.
+// It lets an external verifier replay EmulateInstruction for exactly one
+// instruction without holding the full memory image.
+type Proof struct {
+	PreState  State       `json:"preState"`
+	PostState State       `json:"postState"`
+	Touched   []WordProof `json:"touched"`
+}
+
+// StepWithProof executes exactly one instruction like Step, but also
+// produces a fault-proof-style witness: the pre- and post-state hashes, and
+// a serialized Proof containing only the memory words memAccess observed
+// being read or written this step.
+//
+// The witness only covers State (registers, PC, condition flags, MCC, and
+// memory); it does not capture or reproduce any device-internal state on
+// c.Bus. A verifier replaying this proof reconstructs what EmulateInstruction
+// did to memory and registers, not what a Keyboard/Timer/MachineControl
+// would have done on the replaying side.
+func (c *CPU) StepWithProof(memAccess Oracle) (preHash, postHash [32]byte, proof []byte) {
+	preState := c.CaptureState()
+	preHash = preState.Hash()
+
+	preTree := newMemoryMerkleTree(&c.Memory)
+
+	// InterpBlocks' Step runs a whole cached block; step through the same
+	// decode cache one instruction at a time instead.
+	mode := c.interpMode
+	if mode == InterpBlocks {
+		c.interpMode = InterpThreaded
+	}
+	c.oracle = memAccess
+	if err := c.Step(); err != nil {
+		log.Printf("StepWithProof: step failed: %v", err)
+	}
+	c.oracle = nil
+	c.interpMode = mode
+
+	postState := c.CaptureState()
+	postHash = postState.Hash()
+
+	p := Proof{PreState: preState, PostState: postState}
+	for _, a := range memAccess.Touched() {
+		p.Touched = append(p.Touched, WordProof{
+			MemAccess: a,
+			Siblings:  preTree.siblingPath(a.Addr),
+		})
+	}
+
+	proof, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("StepWithProof: failed to marshal proof: %v", err)
+	}
+	return preHash, postHash, proof
+}